@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -39,10 +40,117 @@ type AddOnTemplateSpec struct {
 	// +required
 	AgentManifests []Manifest `json:"agentManifests"`
 
+	// Values configures where the values used to render templated AgentManifests come from.
+	// Only consulted for Manifests whose TemplateEngine is not None.
+	// +optional
+	Values *ManifestValues `json:"values,omitempty"`
+
 	// Registration holds the registration configuration for the addon
 	// +kubebuilder:validation:Required
 	// +required
 	Registration []RegistrationSpec `json:"registration"`
+
+	// HealthCheck specifies how the addon manager determines the availability of the addon
+	// agent workloads deployed by AgentManifests. If not set, the addon manager falls back to
+	// looking up a single Deployment among AgentManifests.
+	// +optional
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+}
+
+// ManifestValues describes where the values used to render AgentManifests templates come
+// from. The addon manager merges NodePlacement, Registries, Proxy and CustomizedVariables
+// from the AddOnDeploymentConfig associated with the ManagedClusterAddOn, together with
+// built-ins .ClusterName, .InstallNamespace and .HubKubeconfigSecret, and renders each
+// Manifest whose TemplateEngine is not None before producing the ManifestWork for a managed
+// cluster.
+type ManifestValues struct {
+	// AddOnDeploymentConfigRef references the AddOnDeploymentConfig whose values are merged
+	// into the render context. If not set, the AddOnDeploymentConfig already bound to the
+	// ManagedClusterAddOn/ClusterManagementAddOn is used.
+	// +optional
+	AddOnDeploymentConfigRef *AddOnDeploymentConfigReference `json:"addOnDeploymentConfigRef,omitempty"`
+}
+
+// AddOnDeploymentConfigReference identifies a namespaced AddOnDeploymentConfig. Since
+// AddOnTemplate is cluster-scoped, unlike a RegistrationSpec permission binding there is no
+// addon namespace to default against, so both fields are required.
+type AddOnDeploymentConfigReference struct {
+	// Namespace of the AddOnDeploymentConfig.
+	// +kubebuilder:validation:Required
+	// +required
+	Namespace string `json:"namespace"`
+
+	// Name of the AddOnDeploymentConfig.
+	// +kubebuilder:validation:Required
+	// +required
+	Name string `json:"name"`
+}
+
+// HealthCheck specifies how to probe the health of the addon agent workloads.
+type HealthCheck struct {
+	// WorkloadProbes lists the workloads whose availability is aggregated into the
+	// ManagedClusterAddOn Available condition. The addon is considered available only if
+	// every listed workload satisfies its Mode.
+	// +kubebuilder:validation:Required
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	WorkloadProbes []WorkloadHealthProbe `json:"workloadProbes"`
+}
+
+// WorkloadProbeKind represents the kind of the workload to probe.
+type WorkloadProbeKind string
+
+const (
+	// WorkloadProbeKindDeployment represents a Deployment workload.
+	WorkloadProbeKindDeployment WorkloadProbeKind = "Deployment"
+	// WorkloadProbeKindDaemonSet represents a DaemonSet workload.
+	WorkloadProbeKindDaemonSet WorkloadProbeKind = "DaemonSet"
+)
+
+// WorkloadAvailabilityMode represents how the availability of a workload is determined.
+type WorkloadAvailabilityMode string
+
+const (
+	// WorkloadAvailabilityModeAllReplicasReady requires all replicas of the workload to be ready.
+	WorkloadAvailabilityModeAllReplicasReady WorkloadAvailabilityMode = "AllReplicasReady"
+	// WorkloadAvailabilityModeAtLeastOneReady requires at least one replica of the workload to be ready.
+	WorkloadAvailabilityModeAtLeastOneReady WorkloadAvailabilityMode = "AtLeastOneReady"
+	// WorkloadAvailabilityModePerNodeReady requires a DaemonSet to have a ready pod on every
+	// node it is scheduled to, i.e. DesiredNumberScheduled == NumberReady.
+	WorkloadAvailabilityModePerNodeReady WorkloadAvailabilityMode = "PerNodeReady"
+)
+
+// WorkloadHealthProbe references a single workload deployed by AgentManifests and describes
+// how its availability should be evaluated.
+// +kubebuilder:validation:XValidation:rule="self.mode != 'PerNodeReady' || self.kind == 'DaemonSet'",message="mode PerNodeReady is only valid for kind DaemonSet"
+// +kubebuilder:validation:XValidation:rule="self.kind != 'DaemonSet' || self.mode == 'PerNodeReady'",message="kind DaemonSet requires mode PerNodeReady"
+// +kubebuilder:validation:XValidation:rule="has(self.name) || has(self.labelSelector)",message="either name or labelSelector must be set"
+type WorkloadHealthProbe struct {
+	// Kind of the workload to probe.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum:=Deployment;DaemonSet
+	// +required
+	Kind WorkloadProbeKind `json:"kind"`
+
+	// Name of the workload. Either Name or LabelSelector must be set.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Namespace of the workload. Defaults to the addon install namespace on the managed cluster.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// LabelSelector selects the workloads of Kind to probe when Name is not set, allowing
+	// multiple workloads of the same Kind, e.g. one DaemonSet per platform, to be probed together.
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+
+	// Mode determines how the readiness of the selected workload(s) is evaluated.
+	// AllReplicasReady and AtLeastOneReady apply to Deployment, PerNodeReady applies to DaemonSet.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum:=AllReplicasReady;AtLeastOneReady;PerNodeReady
+	// +required
+	Mode WorkloadAvailabilityMode `json:"mode"`
 }
 
 // Manifest represents a resource to be deployed on the managed cluster.
@@ -50,8 +158,29 @@ type Manifest struct {
 	// +kubebuilder:validation:EmbeddedResource
 	// +kubebuilder:pruning:PreserveUnknownFields
 	runtime.RawExtension `json:",inline"`
+
+	// TemplateEngine selects how the manifest is rendered before being applied to a managed
+	// cluster. Defaults to None, in which case the manifest is applied as-is.
+	// +optional
+	// +kubebuilder:validation:Enum:=None;GoTemplate;Helm
+	// +kubebuilder:default:=None
+	TemplateEngine ManifestTemplateEngine `json:"templateEngine,omitempty"`
 }
 
+// ManifestTemplateEngine represents the templating engine used to render a Manifest.
+type ManifestTemplateEngine string
+
+const (
+	// ManifestTemplateEngineNone means the manifest is applied as-is, with no rendering.
+	ManifestTemplateEngineNone ManifestTemplateEngine = "None"
+	// ManifestTemplateEngineGoTemplate means the manifest is rendered with Go's text/template
+	// engine.
+	ManifestTemplateEngineGoTemplate ManifestTemplateEngine = "GoTemplate"
+	// ManifestTemplateEngineHelm means the manifest is rendered with Helm's templating
+	// functions.
+	ManifestTemplateEngineHelm ManifestTemplateEngine = "Helm"
+)
+
 // RegistrationType represents the type of the registration configuration,
 // it could be KubeClient or CustomSigner
 type RegistrationType string
@@ -74,6 +203,9 @@ const (
 	// CSRApproveStrategyNone means that the CSR will not be approved
 	// automatically, users need to approve them by themselves
 	CSRApproveStrategyNone CSRApproveStrategyType = "None"
+	// CSRApproveStrategyConditional means the CSR is automatically approved only if it
+	// satisfies the rules declared in the accompanying ApprovalPolicy, and is denied otherwise.
+	CSRApproveStrategyConditional CSRApproveStrategyType = "Conditional"
 )
 
 // RegistrationSpec describes how to register an addon agent to the hub cluster.
@@ -86,6 +218,7 @@ const (
 // whose content includes key/cert and kubeconfig. Otherwise, If the RegistrationType type is
 // CustomSigner the secret name will be "{addon name}-{signer name}-client-cert" whose content
 // includes key/cert.
+// +kubebuilder:validation:XValidation:rule="self.approveStrategy != 'Conditional' || has(self.approvalPolicy)",message="approvalPolicy is required when approveStrategy is Conditional"
 type RegistrationSpec struct {
 	// Type of the registration configuration
 	// +kubebuilder:validation:Required
@@ -94,9 +227,15 @@ type RegistrationSpec struct {
 
 	// ApproveStrategy represents how to approve the addon registration.
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Enum:=Auto;None
+	// +kubebuilder:validation:Enum:=Auto;None;Conditional
 	ApproveStrategy CSRApproveStrategyType `json:"approveStrategy"`
 
+	// ApprovalPolicy is required when ApproveStrategy is Conditional. The hub CSR approver
+	// walks the policy rules in order and denies the CSR on the first violation, emitting an
+	// Event with the reason.
+	// +optional
+	ApprovalPolicy *CSRApprovalPolicy `json:"approvalPolicy,omitempty"`
+
 	// KubeClient holds the configuration of the KubeClient type registration
 	// +optional
 	KubeClient *KubeClientRegistrationConfig `json:"kubeClient,omitempty"`
@@ -104,6 +243,66 @@ type RegistrationSpec struct {
 	// KubeClient holds the configuration of the CustomSigner type registration
 	// required when the Type is CustomSigner
 	CustomSigner *CustomSignerRegistrationConfig `json:"customSigner,omitempty"`
+
+	// SubjectTemplate is used to render the subject and SANs of the CSR the addon agent
+	// submits during registration. If not set, the default subject with a group of
+	// "system:open-cluster-management:cluster:<cluster-name>:addon:<addon-name>" is used.
+	// +optional
+	SubjectTemplate *SubjectTemplate `json:"subjectTemplate,omitempty"`
+}
+
+// SubjectTemplate defines the additional subject and SANs to embed in the CSR the addon agent
+// submits during registration. CommonNameTemplate/Organizations support the placeholders
+// {{.ClusterName}}, {{.AddonName}} and {{.AgentName}}, which are rendered by the registration
+// agent on the spoke before the CSR is submitted. The hub CSR approver/signer validates that
+// the rendered subject matches the template before approving/signing the CSR.
+type SubjectTemplate struct {
+	// Organizations lists additional groups, on top of the default addon agent group, that the
+	// rendered subject's O fields should contain.
+	// +optional
+	Organizations []string `json:"organizations,omitempty"`
+
+	// CommonNameTemplate overrides the CN of the rendered subject. Supports the placeholders
+	// {{.ClusterName}}, {{.AddonName}} and {{.AgentName}}.
+	// +optional
+	CommonNameTemplate string `json:"commonNameTemplate,omitempty"`
+
+	// DNSNames lists additional DNS SANs to embed in the CSR.
+	// +optional
+	DNSNames []string `json:"dnsNames,omitempty"`
+
+	// URIs lists additional URI SANs to embed in the CSR, for addons whose agents need a
+	// SAN-based identity for non-kube endpoints.
+	// +optional
+	URIs []string `json:"uris,omitempty"`
+}
+
+// CSRApprovalPolicy declares the rules a CSR must satisfy to be automatically approved when
+// ApproveStrategy is Conditional. This mirrors the kubelet TLS bootstrap approver model so
+// "Auto" for a signer does not have to mean blanket approval of every CSR that matches it.
+type CSRApprovalPolicy struct {
+	// AllowedCommonNameRegex is a regular expression the CSR's requested CN must match.
+	// +optional
+	AllowedCommonNameRegex string `json:"allowedCommonNameRegex,omitempty"`
+
+	// AllowedOrganizations lists the O values allowed in the CSR's requested subject. The CSR
+	// is denied if it requests an O outside this set.
+	// +optional
+	AllowedOrganizations []string `json:"allowedOrganizations,omitempty"`
+
+	// MaxDuration is the maximum certificate duration the CSR is allowed to request.
+	// +optional
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+
+	// AllowedSANs lists the DNS/URI SANs allowed in the CSR. The CSR is denied if it requests
+	// a SAN outside this list.
+	// +optional
+	AllowedSANs []string `json:"allowedSANs,omitempty"`
+
+	// RequiredSubmitterGroup, if set, requires the identity submitting the CSR to already
+	// belong to this group, e.g. a bootstrap-token-style "system:bootstrappers:..." group.
+	// +optional
+	RequiredSubmitterGroup string `json:"requiredSubmitterGroup,omitempty"`
 }
 
 type KubeClientRegistrationConfig struct {
@@ -112,19 +311,82 @@ type KubeClientRegistrationConfig struct {
 	Permission *HubPermissionConfig `json:"permission,omitempty"`
 }
 
+// HubPermissionConfigType represents the type of the permission binding configuration.
+type HubPermissionConfigType string
+
+const (
+	// HubPermissionConfigTypeCurrentCluster means a RoleBinding will be created in the managed
+	// cluster namespace on the hub cluster to bind the referenced ClusterRole to the addon agent
+	// group.
+	HubPermissionConfigTypeCurrentCluster HubPermissionConfigType = "CurrentCluster"
+	// HubPermissionConfigTypeSingleNamespace means a RoleBinding will be created in a
+	// user-specified namespace on the hub cluster to bind the referenced Role/ClusterRole to the
+	// addon agent group.
+	HubPermissionConfigTypeSingleNamespace HubPermissionConfigType = "SingleNamespace"
+)
+
 // HubPermissionConfig configures the permission of the addon agent to access the hub cluster.
-// Will create a RoleBinding in the same namespace as the managedClusterAddon to bind the user
-// provided ClusterRole/Role to the "system:open-cluster-management:cluster:<cluster-name>:addon:<addon-name>"
-// Group.
+// Will create a RoleBinding to bind the user provided ClusterRole/Role to the
+// "system:open-cluster-management:cluster:<cluster-name>:addon:<addon-name>" Group.
+// +kubebuilder:validation:XValidation:rule="self.type != 'CurrentCluster' || has(self.currentCluster)",message="currentCluster is required when type is CurrentCluster"
+// +kubebuilder:validation:XValidation:rule="self.type != 'SingleNamespace' || has(self.singleNamespace)",message="singleNamespace is required when type is SingleNamespace"
 type HubPermissionConfig struct {
+	// Type of the permission binding config. CurrentCluster binds the ClusterRole in the
+	// managed cluster namespace, SingleNamespace binds the Role/ClusterRole in the namespace
+	// specified by SingleNamespace.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum:=CurrentCluster;SingleNamespace
+	// +kubebuilder:default:=CurrentCluster
+	// +required
+	Type HubPermissionConfigType `json:"type"`
+
+	// CurrentCluster is required when the Type is CurrentCluster.
+	// +optional
+	CurrentCluster *CurrentHubClusterRoleBindingConfig `json:"currentCluster,omitempty"`
+
+	// SingleNamespace is required when the Type is SingleNamespace.
+	// +optional
+	SingleNamespace *SingleNamespaceBindingConfig `json:"singleNamespace,omitempty"`
+
 	// ClusterRoleName of the permission setting cluster role.
+	// Deprecated: use CurrentCluster.ClusterRoleName instead. Will be removed in a future release.
+	// For one release, a mutating webhook in the addon manager (not this API package) converts
+	// a request that only sets ClusterRoleName into an equivalent CurrentCluster on admission,
+	// so the typed fields above are always what the controller reconciles against; if both the
+	// legacy and typed fields are set, the typed field takes precedence.
 	// +optional
 	ClusterRoleName string `json:"clusterRoleName,omitempty"`
 	// RoleName of the permission setting role in the same namespace as the managedClusterAddon.
+	// Deprecated: use SingleNamespace instead. Will be removed in a future release. Converted to
+	// an equivalent SingleNamespace on admission the same way as ClusterRoleName above.
 	// +optional
 	RoleName string `json:"roleName,omitempty"`
 }
 
+// CurrentHubClusterRoleBindingConfig binds a ClusterRole to the addon agent group in the
+// managed cluster namespace on the hub cluster.
+type CurrentHubClusterRoleBindingConfig struct {
+	// ClusterRoleName of the permission setting cluster role.
+	// +kubebuilder:validation:Required
+	// +required
+	ClusterRoleName string `json:"clusterRoleName"`
+}
+
+// SingleNamespaceBindingConfig binds a Role/ClusterRole to the addon agent group in a
+// user-specified namespace on the hub cluster.
+type SingleNamespaceBindingConfig struct {
+	// Namespace on the hub cluster to create the RoleBinding in.
+	// +kubebuilder:validation:Required
+	// +required
+	Namespace string `json:"namespace"`
+
+	// RoleRef refers to the Role or ClusterRole to bind the addon agent group to.
+	// +kubebuilder:validation:Required
+	// +required
+	RoleRef rbacv1.RoleRef `json:"roleRef"`
+}
+
+// +kubebuilder:validation:XValidation:rule="self.signingCA != null || size(self.signingCARefs) > 0",message="at least one of signingCA or signingCARefs must be set"
 type CustomSignerRegistrationConfig struct {
 	// Name of the signer
 	// +required
@@ -132,16 +394,50 @@ type CustomSignerRegistrationConfig struct {
 	// +kubebuilder:validation:MinLength=5
 	Name string `json:"name"`
 	// SigningCARef represents the reference of the secret to sign the CSR
-	// +kubebuilder:validation:Required
-	SigningCA SigningCARef `json:"signingCA"`
+	// Deprecated: use SigningCARefs instead, which allows a new CA to be added before this one
+	// is removed. Will be removed in a future release.
+	// +optional
+	SigningCA *SigningCARef `json:"signingCA,omitempty"`
+	// SigningCARefs lists the secrets that may sign the CSR. Multiple entries allow a new CA to
+	// be introduced before an old one is removed. At least one of SigningCA or SigningCARefs
+	// must be set.
+	// +optional
+	SigningCARefs []SigningCARef `json:"signingCARefs,omitempty"`
+	// Rotation configures automatic re-issuance of the agent certificate signed by SigningCA(Refs)
+	// before it expires.
+	// +optional
+	Rotation *CertRotation `json:"rotation,omitempty"`
 }
 
-// SigningCARef is the reference to the signing CA secret
+// SigningCARef is the reference to the signing CA secret. Either Name/Namespace or CABundle
+// must be set; if CABundle is set, Name/Namespace are ignored.
+// +kubebuilder:validation:XValidation:rule="(has(self.name) && has(self.namespace)) || has(self.caBundle)",message="either name and namespace, or caBundle, must be set"
 type SigningCARef struct {
 	// Namespace of the signing CA secret
-	// +kubebuilder:validation:Required
-	Namespace string `json:"namespace"`
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
 	// Name of the signing CA secret
+	// +optional
+	Name string `json:"name,omitempty"`
+	// CABundle is an inline PEM-encoded CA bundle, for air-gapped setups where the CA secret is
+	// not reachable from the hub. If set, Namespace/Name are ignored.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+}
+
+// CertRotation configures when the hub signer re-issues an agent certificate ahead of expiry.
+// The addon manager surfaces a CertificateRotation condition on the ManagedClusterAddOn and
+// records Events when rotation occurs or fails, so operators can monitor custom-signer addons
+// without reading raw secrets.
+type CertRotation struct {
+	// Duration is the validity duration of certificates issued by the hub signer.
 	// +kubebuilder:validation:Required
-	Name string `json:"name"`
+	// +required
+	Duration metav1.Duration `json:"duration"`
+
+	// RenewBefore is how long before the certificate's expiry the hub signer re-issues it and
+	// pushes a new "{addon}-{signer}-client-cert" secret via ManifestWork.
+	// +kubebuilder:validation:Required
+	// +required
+	RenewBefore metav1.Duration `json:"renewBefore"`
 }